@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// generateZoneKey creates a throwaway ED25519 DNSKEY/private-key pair for
+// zone, so tests can sign synthetic RRsets without touching the network.
+func generateZoneKey(t *testing.T, zone string) (*dns.DNSKEY, crypto.Signer) {
+	t.Helper()
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: dns.Fqdn(zone), Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ED25519,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		t.Fatalf("generated key does not implement crypto.Signer")
+	}
+	return key, signer
+}
+
+// signRRset signs rrset (all owned by zone) with key/signer, returning an
+// RRSIG covering it, the way a real authoritative server would staple one.
+func signRRset(t *testing.T, zone string, key *dns.DNSKEY, signer crypto.Signer, rrset []dns.RR) *dns.RRSIG {
+	t.Helper()
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: dns.Fqdn(zone), Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+		Algorithm:  key.Algorithm,
+		SignerName: dns.Fqdn(zone),
+		KeyTag:     key.KeyTag(),
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+	}
+	if err := sig.Sign(signer, rrset); err != nil {
+		t.Fatalf("signing rrset: %v", err)
+	}
+	return sig
+}
+
+func TestAuthenticatedDenialRequiresValidSignature(t *testing.T) {
+	zone := "example."
+	key, signer := generateZoneKey(t, zone)
+	keys := []*dns.DNSKEY{key}
+
+	nsec := &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: "a." + zone, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 3600},
+		NextDomain: "b." + zone,
+		TypeBitMap: []uint16{dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC},
+	}
+	sig := signRRset(t, zone, key, signer, []dns.RR{nsec})
+
+	t.Run("valid signature authenticates the denial", func(t *testing.T) {
+		resp := &dns.Msg{Ns: []dns.RR{nsec, sig}}
+		if !authenticatedDenial(resp, keys) {
+			t.Fatal("expected a validly signed NSEC to authenticate the denial")
+		}
+	})
+
+	t.Run("NSEC with no RRSIG at all is not authenticated", func(t *testing.T) {
+		resp := &dns.Msg{Ns: []dns.RR{nsec}}
+		if authenticatedDenial(resp, keys) {
+			t.Fatal("bare NSEC with no signature must not authenticate the denial")
+		}
+	})
+
+	t.Run("NSEC with a forged RRSIG is not authenticated", func(t *testing.T) {
+		_, attacker := generateZoneKey(t, zone)
+		forged := signRRset(t, zone, key, attacker, []dns.RR{nsec}) // claims key's tag, signed by a different key
+		resp := &dns.Msg{Ns: []dns.RR{nsec, forged}}
+		if authenticatedDenial(resp, keys) {
+			t.Fatal("NSEC with a signature that fails verification must not authenticate the denial")
+		}
+	})
+
+	t.Run("no denial records at all", func(t *testing.T) {
+		resp := &dns.Msg{Ns: []dns.RR{}}
+		if authenticatedDenial(resp, keys) {
+			t.Fatal("empty authority section must not authenticate a denial")
+		}
+	})
+}
+
+func TestVerifyRRSIGMatchesKeyByTagAndAlgorithm(t *testing.T) {
+	zone := "example."
+	key, signer := generateZoneKey(t, zone)
+	other, _ := generateZoneKey(t, zone)
+
+	a := &dns.A{Hdr: dns.RR_Header{Name: "www." + zone, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 3600}, A: net.ParseIP("192.0.2.1")}
+	rrset := []dns.RR{a}
+	sig := signRRset(t, zone, key, signer, rrset)
+
+	if err := verifyRRSIG(rrset, sig, []*dns.DNSKEY{key}); err != nil {
+		t.Fatalf("expected valid signature to verify, got: %v", err)
+	}
+	if err := verifyRRSIG(rrset, sig, []*dns.DNSKEY{other}); err == nil {
+		t.Fatal("expected verification against the wrong key to fail")
+	}
+}
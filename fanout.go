@@ -0,0 +1,132 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// resolverAliases lets --server accept a handful of well-known public
+// resolvers by name instead of memorising their IPs, the same shorthand
+// doggo supports.
+var resolverAliases = map[string]string{
+	"google":     "8.8.8.8",
+	"cloudflare": "1.1.1.1",
+	"quad9":      "9.9.9.9",
+	"opendns":    "208.67.222.222",
+}
+
+// parseServers splits a --server value on commas and resolves any known
+// alias, so `--server 8.8.8.8,1.1.1.1,quad9` and `--server quad9` both work.
+func parseServers(raw string) []string {
+	parts := strings.Split(raw, ",")
+	servers := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if addr, ok := resolverAliases[strings.ToLower(p)]; ok {
+			p = addr
+		}
+		servers = append(servers, p)
+	}
+	return servers
+}
+
+// fanOutQuery asks every server in servers the same question concurrently
+// over transportKind, returning one Hop per server labelled with the
+// original --server text (so aliases like "quad9" still read back as
+// "quad9" rather than its IP) and a report on whether they agree.
+//
+// onHop, if non-nil, is called as soon as each server's hop completes
+// (in whatever order they race in), not after the whole fan-out finishes,
+// so --output jsonl can stream results as they arrive.
+func fanOutQuery(domain string, qtype, qclass uint16, transportKind string, servers []string, spkiPin string, onHop func(Hop)) ([]Hop, ConsistencyReport) {
+	hops := make([]Hop, len(servers))
+
+	var wg sync.WaitGroup
+	var emitMu sync.Mutex
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			transport, addr, err := NewTransport(transportKind, server, spkiPin)
+			if err != nil {
+				hops[i] = Hop{Server: server, ServerAddr: server, Error: err}
+			} else {
+				hop := directQuery(domain, qtype, qclass, transport, addr)
+				hop.Server = server // keep the label the user typed, e.g. "quad9"
+				hops[i] = hop
+			}
+
+			if onHop != nil {
+				emitMu.Lock()
+				onHop(hops[i])
+				emitMu.Unlock()
+			}
+		}(i, server)
+	}
+	wg.Wait()
+
+	return hops, assessConsistency(hops)
+}
+
+// ConsistencyReport summarizes how a fan-out's responses compared: whether
+// they agree and which server answered fastest.
+type ConsistencyReport struct {
+	Consistent bool
+	Conclusive bool   // false if every server errored, so Consistent is meaningless
+	Fastest    string // server label, empty if every hop errored
+}
+
+// assessConsistency compares each hop's answer set (formatted and sorted so
+// record order doesn't cause a false mismatch) and finds the quickest
+// successful responder. With zero successful responses there's nothing to
+// compare, so Conclusive is false rather than reporting agreement by default.
+func assessConsistency(hops []Hop) ConsistencyReport {
+	report := ConsistencyReport{Consistent: true}
+
+	var reference string
+	haveReference := false
+	var fastest *Hop
+
+	for i := range hops {
+		hop := &hops[i]
+		if hop.Error != nil {
+			continue
+		}
+
+		answer := answerFingerprint(hop.Answer)
+		if !haveReference {
+			reference = answer
+			haveReference = true
+		} else if answer != reference {
+			report.Consistent = false
+		}
+
+		if fastest == nil || hop.RTT < fastest.RTT {
+			fastest = hop
+		}
+	}
+
+	report.Conclusive = haveReference
+	if fastest != nil {
+		report.Fastest = fastest.Server
+	}
+	return report
+}
+
+// answerFingerprint renders an answer set as a sorted, comma-joined string
+// so two equivalent RRsets compare equal regardless of the order a server
+// happened to return them in.
+func answerFingerprint(rrs []dns.RR) string {
+	parts := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		parts = append(parts, formatRR(rr))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
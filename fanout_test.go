@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func aRecord(name, ip string) dns.RR {
+	return &dns.A{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.ParseIP(ip)}
+}
+
+func TestAssessConsistencyAgreement(t *testing.T) {
+	hops := []Hop{
+		{Server: "a", Answer: []dns.RR{aRecord("example.", "192.0.2.1")}},
+		{Server: "b", Answer: []dns.RR{aRecord("example.", "192.0.2.1")}},
+	}
+	report := assessConsistency(hops)
+	if !report.Conclusive || !report.Consistent {
+		t.Fatalf("expected conclusive agreement, got %+v", report)
+	}
+}
+
+func TestAssessConsistencyDisagreement(t *testing.T) {
+	hops := []Hop{
+		{Server: "a", Answer: []dns.RR{aRecord("example.", "192.0.2.1")}},
+		{Server: "b", Answer: []dns.RR{aRecord("example.", "192.0.2.2")}},
+	}
+	report := assessConsistency(hops)
+	if !report.Conclusive || report.Consistent {
+		t.Fatalf("expected conclusive disagreement, got %+v", report)
+	}
+}
+
+func TestAssessConsistencyAllErroredIsInconclusive(t *testing.T) {
+	hops := []Hop{
+		{Server: "a", Error: errors.New("timeout")},
+		{Server: "b", Error: errors.New("timeout")},
+	}
+	report := assessConsistency(hops)
+	if report.Conclusive {
+		t.Fatalf("expected an all-error fan-out to be inconclusive, got %+v", report)
+	}
+	if report.Fastest != "" {
+		t.Fatalf("expected no fastest server when every hop errored, got %q", report.Fastest)
+	}
+}
+
+func TestParseServersEmptyAfterTrimming(t *testing.T) {
+	for _, raw := range []string{",", " , ", "", "  "} {
+		if got := parseServers(raw); len(got) != 0 {
+			t.Fatalf("parseServers(%q) = %v, want empty", raw, got)
+		}
+	}
+}
+
+func TestParseServersResolvesAliases(t *testing.T) {
+	got := parseServers("quad9, 1.1.1.1,,google")
+	want := []string{"9.9.9.9", "1.1.1.1", "8.8.8.8"}
+	if len(got) != len(want) {
+		t.Fatalf("parseServers() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("parseServers()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// formatRR renders an RR the way dig-style tools present the record types
+// users actually care about, falling back to the library's zone-file
+// presentation for anything we don't special-case.
+func formatRR(rr dns.RR) string {
+	switch rec := rr.(type) {
+	case *dns.MX:
+		return fmt.Sprintf("%s MX %d %s", rec.Hdr.Name, rec.Preference, rec.Mx)
+	case *dns.SRV:
+		return fmt.Sprintf("%s SRV %d %d %d %s", rec.Hdr.Name, rec.Priority, rec.Weight, rec.Port, rec.Target)
+	case *dns.TXT:
+		return fmt.Sprintf("%s TXT %s", rec.Hdr.Name, strings.Join(rec.Txt, " "))
+	case *dns.SOA:
+		return fmt.Sprintf("%s SOA %s %s %d %d %d %d %d", rec.Hdr.Name, rec.Ns, rec.Mbox, rec.Serial, rec.Refresh, rec.Retry, rec.Expire, rec.Minttl)
+	case *dns.CAA:
+		return fmt.Sprintf("%s CAA %d %s %q", rec.Hdr.Name, rec.Flag, rec.Tag, rec.Value)
+	case *dns.CNAME:
+		return fmt.Sprintf("%s CNAME %s", rec.Hdr.Name, rec.Target)
+	case *dns.PTR:
+		return fmt.Sprintf("%s PTR %s", rec.Hdr.Name, rec.Ptr)
+	case *dns.A:
+		return fmt.Sprintf("%s A %s", rec.Hdr.Name, rec.A)
+	case *dns.AAAA:
+		return fmt.Sprintf("%s AAAA %s", rec.Hdr.Name, rec.AAAA)
+	case *dns.DNSKEY:
+		return fmt.Sprintf("%s DNSKEY %d %d %d", rec.Hdr.Name, rec.Flags, rec.Protocol, rec.Algorithm)
+	case *dns.DS:
+		return fmt.Sprintf("%s DS %d %d %d %s", rec.Hdr.Name, rec.KeyTag, rec.Algorithm, rec.DigestType, rec.Digest)
+	default:
+		return rr.String()
+	}
+}
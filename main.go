@@ -1,153 +1,256 @@
 package main
 
 import (
-	"context"
+	"flag"
 	"fmt"
-	"net"
 	"os"
 	"strings"
-	"time"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	ansiBold  = "\033[1m"
+	ansiGreen = "\033[32m"
+	ansiGrey  = "\033[90m"
+	ansiRed   = "\033[31m"
+	ansiReset = "\033[0m"
 )
 
 const (
-	ansiBold       = "\033[1m"
-	ansiGreen      = "\033[32m"
-	ansiGrey       = "\033[90m"
-	ansiRed        = "\033[31m"
-	ansiReset      = "\033[0m"
-	cancelAfter    = 100 * time.Millisecond
-	expectedArgs   = 3
-	maxDepth       = 5
-	parentTimeout  = 3 * time.Second
-	resolveTimeout = 3 * time.Second
-	resolverAddr   = "8.8.8.8"
+	minArgs = 2
+	maxArgs = 3
 )
 
 func main() {
-	if len(os.Args) != expectedArgs {
-		fmt.Println("Usage: dnsv <domain> <query>")
+	dnssec := flag.Bool("dnssec", false, "validate the chain of trust (DNSKEY/DS/RRSIG) along the resolution path")
+	transportFlag := flag.String("transport", "udp", "transport to use with --server: udp, tcp, tls (dot), https (doh), quic (doq)")
+	server := flag.String("server", "", "query this resolver directly instead of walking the tree from the root; accepts tls://, https://, quic:// URLs, and comma-separated lists for fan-out")
+	outputFlag := flag.String("output", "pretty", "output format: pretty, json, jsonl, short")
+	spkiPin := flag.String("spki-pin", "", "hex SHA-256 SPKI pin the --transport tls/dot server's certificate must match")
+	flag.Usage = func() {
+		fmt.Println("Usage: dnsv [--dnssec] [--server <addr>[,addr...]] [--transport <name>] [--spki-pin <hex>] [--output <format>] <domain> <query> [class]")
+	}
+	flag.Parse()
+
+	if !colorEnabled() {
+		ansiBold, ansiGreen, ansiGrey, ansiRed, ansiReset = "", "", "", "", ""
+	}
+
+	output, err := parseOutputFormat(*outputFlag)
+	if err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
-	domain := os.Args[1]
-	queryType := os.Args[2]
 
-	fmt.Println("Starting DNS visualization...")
-	visualizeDNSResolution(domain, 1, queryType, parentTimeout)
-}
+	args := flag.Args()
+	if len(args) < minArgs || len(args) > maxArgs {
+		flag.Usage()
+		os.Exit(1)
+	}
+	domain := args[0]
+	queryType := args[1]
+	className := "IN"
+	if len(args) == maxArgs {
+		className = args[2]
+	}
 
-// visualizeDNSResolution handles the DNS query recursively, printing out the path.
-func visualizeDNSResolution(domain string, depth int, queryType string, parentTimeout time.Duration) {
-	if depth > maxDepth { // Limit recursion depth to prevent infinite loops
-		return
+	qtype, ok := dns.StringToType[strings.ToUpper(queryType)]
+	if !ok {
+		fmt.Printf("Unknown query type: %s\n", queryType)
+		os.Exit(1)
+	}
+	qclass, ok := dns.StringToClass[strings.ToUpper(className)]
+	if !ok {
+		fmt.Printf("Unknown query class: %s\n", className)
+		os.Exit(1)
 	}
 
-	canceled := parentTimeout < cancelAfter // Simulate a cancel timeout for some requests
-	result := resolve(domain, resolverAddr, queryType)
-	displayResult(resolverAddr, result, depth, canceled)
+	if output == outputPretty {
+		fmt.Println("Starting DNS visualization...")
+	}
+
+	if *server != "" {
+		if *dnssec {
+			fmt.Println("--dnssec is not supported together with --server: a direct query isn't chain-validated")
+			os.Exit(1)
+		}
+
+		servers := parseServers(*server)
+		if len(servers) == 0 {
+			fmt.Println("--server given but no usable server address found")
+			os.Exit(1)
+		}
+		if len(servers) > 1 {
+			runFanOut(domain, qtype, qclass, *transportFlag, servers, *spkiPin, output)
+			return
+		}
 
-	// Continue recursion unless canceled or there was an error
-	if !canceled && result.Error == nil {
-		visualizeDNSResolution(domain, depth+1, "NS", parentTimeout-result.TimeTaken)
+		runDirect(domain, qtype, qclass, *transportFlag, servers[0], *spkiPin, output)
+		return
 	}
+
+	runIterative(domain, qtype, qclass, *dnssec, output)
 }
 
-// resolve performs a DNS resolution for the given domain and returns the result.
-func resolve(domain, server, queryType string) DNSResult {
-	start := time.Now()
-	var ns string
-	if server == "" {
-		ns = "root"        // Root server
-		server = "8.8.8.8" // Default to Google's DNS server
-	} else {
-		ns = server
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
-	defer cancel()
-
-	r := net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, _ /* address */ string) (net.Conn, error) {
-			d := net.Dialer{
-				Timeout: time.Second,
-			}
-			return d.DialContext(ctx, network, server+":53")
-		},
-	}
-
-	var records []string
-	var err error
-	switch queryType {
-	case "NS":
-		nsRecords, lookupErr := r.LookupNS(ctx, domain)
-		err = lookupErr
-		if err == nil {
-			for _, ns := range nsRecords {
-				records = append(records, ns.Host)
-			}
-		}
+// runIterative drives a root-down walk and renders it in whichever format
+// the user asked for.
+func runIterative(domain string, qtype, qclass uint16, dnssec bool, output outputFormat) {
+	var onHop func(Hop)
+	if output == outputJSONL {
+		onHop = jsonlHopWriter()
+	}
+
+	hops, err := iterativeResolve(domain, qtype, qclass, dnssec, onHop)
+
+	switch output {
+	case outputJSON:
+		renderJSON(domain, hops)
+	case outputShort:
+		renderShort(hops)
+	case outputJSONL:
+		// already streamed via onHop
 	default:
-		records, err = r.LookupHost(ctx, domain)
+		displayHops(domain, hops, dnssec)
 	}
 
-	duration := time.Since(start)
 	if err != nil {
-		return DNSResult{
-			Query:       domain,
-			Server:      ns,
-			QueryType:   queryType,
-			TimeTaken:   duration,
-			ResponseMsg: "NXDOMAIN",
-			Error:       err,
+		if output == outputPretty {
+			fmt.Printf("\n%s# %s%s\n", ansiGrey, err, ansiReset)
 		}
+		os.Exit(1)
+	}
+}
+
+// runDirect queries a single user-chosen resolver over the chosen transport.
+// It never validates DNSSEC (main rejects --dnssec together with --server),
+// so it always renders as an unchecked hop rather than claiming a status.
+func runDirect(domain string, qtype, qclass uint16, transportKind, server, spkiPin string, output outputFormat) {
+	transport, addr, err := NewTransport(transportKind, server, spkiPin)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
+	hop := directQuery(domain, qtype, qclass, transport, addr)
 
-	return DNSResult{
-		Query:       domain,
-		Server:      ns,
-		QueryType:   queryType,
-		TimeTaken:   duration,
-		ResponseMsg: strings.Join(records, ", "),
-		Error:       nil,
+	switch output {
+	case outputJSON:
+		renderJSON(domain, []Hop{hop})
+	case outputJSONL:
+		jsonlHopWriter()(hop)
+	case outputShort:
+		renderShort([]Hop{hop})
+	default:
+		displayHops(domain, []Hop{hop}, false)
 	}
-}
 
-// DNSResult stores the result of a DNS query.
-type DNSResult struct {
-	Query       string
-	Server      string
-	QueryType   string
-	TimeTaken   time.Duration
-	ResponseMsg string
-	Error       error
+	if hop.Error != nil {
+		os.Exit(1)
+	}
 }
 
-// displayResult prints the DNS result in a structured format.
-func displayResult(server string, result DNSResult, depth int, canceled bool) { // nolint:revive // flag-parameter no control flag
-	if depth == 1 {
-		printHeader("DNS server", server)
+// runFanOut queries every server concurrently and renders the comparison.
+func runFanOut(domain string, qtype, qclass uint16, transportKind string, servers []string, spkiPin string, output outputFormat) {
+	var onHop func(Hop)
+	if output == outputJSONL {
+		onHop = jsonlHopWriter()
 	}
-	indent := strings.Repeat("│   ", depth-1)
-	if depth > 0 {
-		fmt.Printf("%s╭─── resolve(%sdomain:%s %q, %squery:%s %q, %sdepth:%s %d)\n", indent, ansiGreen, ansiReset, result.Query, ansiGreen, ansiReset, result.QueryType, ansiGreen, ansiReset, depth)
+
+	hops, report := fanOutQuery(domain, qtype, qclass, transportKind, servers, spkiPin, onHop)
+
+	switch output {
+	case outputJSON:
+		renderJSON(domain, hops)
+	case outputShort:
+		renderShort(hops)
+	case outputJSONL:
+		// already streamed via onHop
+	default:
+		displayFanOut(domain, hops, report)
 	}
+}
+
+// displayHops prints the iterative walk as a tree, one frame per hop, from
+// the root server down to whichever nameserver produced the final answer.
+// When dnssec is set, each hop's zone cut is annotated with a ✓/✗ next to
+// its validation status.
+func displayHops(domain string, hops []Hop, dnssec bool) {
+	printHeader("resolving", domain)
+
+	for _, hop := range hops {
+		indent := strings.Repeat("│   ", hop.Depth-1)
+		fmt.Printf("%s╭─── query(%sserver:%s %s (%s), %squery:%s %q, %stype:%s %s, %sdepth:%s %d)\n",
+			indent, ansiGreen, ansiReset, hop.Server, hop.ServerAddr, ansiGreen, ansiReset, hop.Query, ansiGreen, ansiReset, hop.QueryType, ansiGreen, ansiReset, hop.Depth)
+
+		msg := fmt.Sprintf("%dms:", hop.RTT.Milliseconds())
+		if hop.Error != nil {
+			msg += ansiGrey + " # ERROR: " + hop.Error.Error() + ansiReset
+		} else {
+			msg += fmt.Sprintf("%s # rcode=%s answer=%d authority=%d additional=%d%s",
+				ansiGrey, hop.Rcode, len(hop.Answer), len(hop.Authority), len(hop.Additional), ansiReset)
+		}
+		if dnssec {
+			msg += " " + dnssecBadge(hop.DNSSEC)
+		}
+		fmt.Printf("%s╰─── %s\n", indent, msg)
 
-	status := "OK"
-	if canceled {
-		status = "CANCELED"
+		if hop.Transport != "" {
+			fmt.Printf("%s    %stransport:%s %s (handshake %dms)\n", indent, ansiGrey, ansiReset, hop.Transport, hop.Handshake.Milliseconds())
+		}
+
+		for _, rr := range hop.Answer {
+			fmt.Printf("%s    %s%s%s\n", indent, ansiGreen, formatRR(rr), ansiReset)
+		}
 	}
+}
 
-	msg := fmt.Sprintf("%dms:", result.TimeTaken.Milliseconds())
-	if result.Error != nil {
-		msg += ansiGrey + " # ERROR: " + result.ResponseMsg + ansiReset
-	} else {
-		msg += ansiGrey + " # " + result.ResponseMsg + ansiReset
+// displayFanOut prints one line per resolver queried in parallel, highlights
+// the fastest responder, and flags whether every resolver agreed.
+func displayFanOut(domain string, hops []Hop, report ConsistencyReport) {
+	printHeader("resolving (fan-out)", domain)
+
+	for _, hop := range hops {
+		marker := "  "
+		if hop.Server == report.Fastest {
+			marker = ansiGreen + "➤ " + ansiReset
+		}
+
+		if hop.Error != nil {
+			fmt.Printf("%s%-20s %s# ERROR: %s%s\n", marker, hop.Server, ansiGrey, hop.Error, ansiReset)
+			continue
+		}
+
+		fmt.Printf("%s%-20s %s%4dms%s  rcode=%s  answers=%d\n",
+			marker, hop.Server, ansiGreen, hop.RTT.Milliseconds(), ansiReset, hop.Rcode, len(hop.Answer))
+		for _, rr := range hop.Answer {
+			fmt.Printf("                     %s%s%s\n", ansiGrey, formatRR(rr), ansiReset)
+		}
 	}
-	if canceled {
-		msg += " == " + status + " =="
+
+	fmt.Println()
+	switch {
+	case !report.Conclusive:
+		fmt.Printf("%s? no resolver answered, consistency unknown%s\n", ansiGrey, ansiReset)
+	case report.Consistent:
+		fmt.Printf("%s✓ all resolvers agree%s\n", ansiGreen, ansiReset)
+	default:
+		fmt.Printf("%s✗ resolvers disagree on the answer%s\n", ansiRed, ansiReset)
 	}
+}
 
-	fmt.Printf("%s╰─── %s\n", indent, msg)
+// dnssecBadge renders a zone cut's validation status as a checkmark/cross
+// plus the status name, colored to match the tree's existing palette.
+func dnssecBadge(status SecurityStatus) string {
+	switch status {
+	case StatusSecure:
+		return ansiGreen + "✓ " + status.String() + ansiReset
+	case StatusInsecure:
+		return ansiGrey + "✓ " + status.String() + ansiReset
+	case StatusBogus:
+		return ansiRed + "✗ " + status.String() + ansiReset
+	default:
+		return ansiGrey + "? " + status.String() + ansiReset
+	}
 }
 
 func printHeader(header, msg string) {
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// outputFormat selects how a resolution is rendered: the default colored
+// tree, a single JSON document, one JSON object per hop (NDJSON), or a
+// dig-style list of just the final answers.
+type outputFormat string
+
+const (
+	outputPretty outputFormat = "pretty"
+	outputJSON   outputFormat = "json"
+	outputJSONL  outputFormat = "jsonl"
+	outputShort  outputFormat = "short"
+)
+
+// parseOutputFormat validates a --output flag value.
+func parseOutputFormat(s string) (outputFormat, error) {
+	switch outputFormat(s) {
+	case outputPretty, outputJSON, outputJSONL, outputShort:
+		return outputFormat(s), nil
+	default:
+		return "", fmt.Errorf("unknown output format: %s", s)
+	}
+}
+
+// hopDoc is the JSON representation of a Hop: the shape documented for
+// --output json/jsonl.
+type hopDoc struct {
+	Query      string   `json:"query"`
+	Server     string   `json:"server"`
+	Transport  string   `json:"transport,omitempty"`
+	QType      string   `json:"qtype"`
+	RTTMs      int64    `json:"rtt_ms"`
+	Rcode      string   `json:"rcode,omitempty"`
+	Answers    []string `json:"answers,omitempty"`
+	Authority  []string `json:"authority,omitempty"`
+	Additional []string `json:"additional,omitempty"`
+	DNSSEC     string   `json:"dnssec_status,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// newHopDoc converts a Hop into its JSON representation.
+func newHopDoc(hop Hop) hopDoc {
+	doc := hopDoc{
+		Query:     hop.Query,
+		Server:    hop.Server,
+		Transport: hop.Transport,
+		QType:     hop.QueryType,
+		RTTMs:     hop.RTT.Milliseconds(),
+		Rcode:     hop.Rcode,
+	}
+	if hop.DNSSEC != StatusIndeterminate {
+		doc.DNSSEC = hop.DNSSEC.String()
+	}
+	if hop.Error != nil {
+		doc.Error = hop.Error.Error()
+	}
+	for _, rr := range hop.Answer {
+		doc.Answers = append(doc.Answers, formatRR(rr))
+	}
+	for _, rr := range hop.Authority {
+		doc.Authority = append(doc.Authority, formatRR(rr))
+	}
+	for _, rr := range hop.Additional {
+		doc.Additional = append(doc.Additional, formatRR(rr))
+	}
+	return doc
+}
+
+// jsonlHopWriter returns an onHop callback that writes one JSON object per
+// hop to stdout as soon as it completes.
+func jsonlHopWriter() func(Hop) {
+	enc := json.NewEncoder(os.Stdout)
+	return func(hop Hop) {
+		_ = enc.Encode(newHopDoc(hop))
+	}
+}
+
+// renderJSON prints the full walk as a single JSON document.
+func renderJSON(domain string, hops []Hop) {
+	docs := make([]hopDoc, len(hops))
+	for i, hop := range hops {
+		docs[i] = newHopDoc(hop)
+	}
+	out := struct {
+		Domain string   `json:"domain"`
+		Hops   []hopDoc `json:"hops"`
+	}{Domain: domain, Hops: docs}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(out)
+}
+
+// renderShort prints just the final answers, one record per line, the way
+// `dig +short` does.
+func renderShort(hops []Hop) {
+	if len(hops) == 0 {
+		return
+	}
+	for _, rr := range hops[len(hops)-1].Answer {
+		fmt.Println(formatRR(rr))
+	}
+}
+
+// colorEnabled reports whether ANSI escapes should be emitted: stdout must
+// be a terminal and NO_COLOR must be unset, per https://no-color.org.
+func colorEnabled() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// directQuery asks a single, user-chosen server over the given transport,
+// with RecursionDesired set since such a server is expected to be a full
+// recursive resolver rather than an authoritative one. It produces a single
+// Hop so the result renders through the same tree as an iterative walk.
+func directQuery(domain string, qtype, qclass uint16, transport Transport, addr string) Hop {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(domain), qtype)
+	m.Question[0].Qclass = qclass
+	m.RecursionDesired = true
+
+	start := time.Now()
+	result, err := transport.Exchange(m, addr)
+	rtt := time.Since(start)
+
+	hop := Hop{
+		Depth:      1,
+		Server:     addr,
+		ServerAddr: addr,
+		Query:      dns.Fqdn(domain),
+		QueryType:  dns.TypeToString[qtype],
+		RTT:        rtt,
+		Transport:  transport.Name(),
+		Handshake:  result.HandshakeTime,
+	}
+	if err != nil {
+		hop.Error = err
+		return hop
+	}
+
+	hop.Rcode = dns.RcodeToString[result.Msg.Rcode]
+	hop.Answer = result.Msg.Answer
+	hop.Authority = result.Msg.Ns
+	hop.Additional = result.Msg.Extra
+	return hop
+}
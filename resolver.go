@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// iterativeMaxDepth bounds how many hops a single walk may take before we
+// give up, guarding against referral loops or misconfigured zones.
+const iterativeMaxDepth = 30
+
+// dnsClientTimeout bounds a single query/response round trip to one hop.
+const dnsClientTimeout = 3 * time.Second
+
+// dnsEDNSBufferSize is advertised via EDNS0 whenever DNSSEC records are
+// requested, since DNSKEY/RRSIG RRsets routinely exceed the 512-byte
+// classic UDP limit.
+const dnsEDNSBufferSize = 4096
+
+// Hop captures everything that happened at one step of an iterative
+// resolution: which server was asked, what it said, and how long it took.
+type Hop struct {
+	Depth      int
+	Server     string // hostname of the server contacted, e.g. "a.root-servers.net"
+	ServerAddr string // IP address actually dialed
+	Query      string
+	QueryType  string
+	RTT        time.Duration
+	Rcode      string
+	Answer     []dns.RR
+	Authority  []dns.RR
+	Additional []dns.RR
+	DNSSEC     SecurityStatus
+	Transport  string // negotiated transport name, e.g. "DoT"; empty for the classic iterative walk
+	Handshake  time.Duration
+	Error      error
+}
+
+// iterativeResolve walks the DNS tree from the root down, following
+// referrals until it finds an answer, hits a terminal rcode, or exceeds
+// iterativeMaxDepth. It never sets the RecursionDesired bit: every query is
+// answered authoritatively (or with a referral) by the server asked.
+//
+// When dnssec is set, each zone cut's DNSKEY is validated against the
+// previous hop's DS before trusting its answer, building an unbroken chain
+// of trust from rootTrustAnchor.
+//
+// onHop, if non-nil, is called with each hop as soon as it's finalized, so
+// callers that want to stream output (e.g. --output jsonl) don't have to
+// wait for the whole walk to finish.
+func iterativeResolve(domain string, qtype, qclass uint16, dnssec bool, onHop func(Hop)) ([]Hop, error) {
+	qname := dns.Fqdn(domain)
+
+	server := rootServers[0]
+	zone := "."
+	var trustedDS []*dns.DS // nil at the root: anchored via rootTrustAnchor instead
+	var hops []Hop
+
+	for depth := 1; depth <= iterativeMaxDepth; depth++ {
+		var cut zoneCut
+		if dnssec {
+			var err error
+			cut, err = validateZoneCut(server.addr, zone, trustedDS, zone == ".")
+			if err != nil && cut.status != StatusInsecure {
+				cut.status = StatusBogus
+			}
+		}
+
+		hop, resp := queryHop(depth, server.name, server.addr, qname, qtype, qclass, dnssec)
+		hop.DNSSEC = cut.status
+
+		if hop.Error == nil {
+			terminal := isTerminalHop(hop)
+			if dnssec && cut.status == StatusSecure {
+				hop.DNSSEC = validateHopContent(resp, cut.keys, terminal)
+			}
+
+			hops = append(hops, hop)
+			emitHop(onHop, hop)
+
+			if terminal {
+				return hops, nil
+			}
+
+			ref, err := nextNameserver(resp, domain)
+			if err != nil {
+				return hops, err
+			}
+
+			if dnssec && cut.status == StatusSecure {
+				trustedDS = extractDS(resp, cut.keys)
+			}
+			zone = ref.zone
+			server = rootServer{name: ref.name, addr: ref.addr}
+			continue
+		}
+
+		hops = append(hops, hop)
+		emitHop(onHop, hop)
+		return hops, hop.Error
+	}
+
+	return hops, fmt.Errorf("exceeded max depth (%d) resolving %s", iterativeMaxDepth, domain)
+}
+
+// isTerminalHop reports whether hop is a terminal answer rather than a
+// referral to walk further: a non-success rcode (e.g. NXDOMAIN), an actual
+// ANSWER RRset, or a NOERROR/NODATA response whose AUTHORITY section carries
+// no NS record to follow.
+func isTerminalHop(hop Hop) bool {
+	return len(hop.Answer) > 0 || hop.Rcode != dns.RcodeToString[dns.RcodeSuccess] || !hasReferral(hop.Authority)
+}
+
+// emitHop calls onHop if the caller supplied one.
+func emitHop(onHop func(Hop), hop Hop) {
+	if onHop != nil {
+		onHop(hop)
+	}
+}
+
+// validateHopContent checks the signature over whatever this hop actually
+// returned: the ANSWER RRset for a final answer, or an authenticated
+// NSEC/NSEC3 denial for a negative response.
+func validateHopContent(resp *dns.Msg, keys []*dns.DNSKEY, terminal bool) SecurityStatus {
+	if !terminal {
+		return StatusSecure // referral itself is validated via its DS, not here
+	}
+	if len(resp.Answer) == 0 {
+		if authenticatedDenial(resp, keys) {
+			return StatusSecure
+		}
+		return StatusBogus
+	}
+
+	rrtype := resp.Answer[0].Header().Rrtype
+	covered, sig := splitRRSIG(resp.Answer, rrtype)
+	if sig == nil || verifyRRSIG(covered, sig, keys) != nil {
+		return StatusBogus
+	}
+	return StatusSecure
+}
+
+// extractDS pulls the DS RRset out of a referral's AUTHORITY section and
+// verifies it against the parent zone's already-validated keys, producing
+// the trust anchor for the next zone cut down. A referral with no DS at all
+// is a legitimate unsigned delegation, so it returns nil rather than an
+// error.
+func extractDS(resp *dns.Msg, keys []*dns.DNSKEY) []*dns.DS {
+	covered, sig := splitRRSIG(resp.Ns, dns.TypeDS)
+	if len(covered) == 0 {
+		return nil
+	}
+	if sig == nil || verifyRRSIG(covered, sig, keys) != nil {
+		return nil
+	}
+
+	ds := make([]*dns.DS, 0, len(covered))
+	for _, rr := range covered {
+		if d, ok := rr.(*dns.DS); ok {
+			ds = append(ds, d)
+		}
+	}
+	return ds
+}
+
+// queryHop sends a single non-recursive query to server and records the
+// result as a Hop, along with the raw response for the caller to follow up
+// on (e.g. extracting a referral). It goes through classicTransport so a
+// truncated UDP reply (routine for a fat DNSKEY/RRSIG RRset) falls back to
+// TCP instead of being silently dropped.
+func queryHop(depth int, server, addr, qname string, qtype, qclass uint16, dnssec bool) (Hop, *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetQuestion(qname, qtype)
+	m.Question[0].Qclass = qclass
+	m.RecursionDesired = false
+	if dnssec {
+		m.SetEdns0(dnsEDNSBufferSize, true) // DO bit
+		m.CheckingDisabled = true           // we validate ourselves, not the server
+	}
+
+	transport := &classicTransport{}
+	start := time.Now()
+	result, err := transport.Exchange(m, addr+":53")
+	rtt := time.Since(start)
+
+	hop := Hop{
+		Depth:      depth,
+		Server:     server,
+		ServerAddr: addr,
+		Query:      qname,
+		QueryType:  dns.TypeToString[qtype],
+		RTT:        rtt,
+	}
+	if err != nil {
+		hop.Error = err
+		return hop, nil
+	}
+
+	resp := result.Msg
+	hop.Rcode = dns.RcodeToString[resp.Rcode]
+	hop.Answer = resp.Answer
+	hop.Authority = resp.Ns
+	hop.Additional = resp.Extra
+	return hop, resp
+}
+
+// hasReferral reports whether an AUTHORITY section carries at least one NS
+// record, i.e. this is a delegation to walk further rather than a terminal
+// answer from the current zone (NODATA's SOA-only authority included).
+func hasReferral(authority []dns.RR) bool {
+	for _, rr := range authority {
+		if _, ok := rr.(*dns.NS); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// referral describes where an iterative walk should go next: the
+// nameserver to ask, and the zone it is authoritative for (the owner name
+// of the NS RRset it was referred by).
+type referral struct {
+	name string
+	addr string
+	zone string
+}
+
+// nextNameserver picks an authority NS record from resp and resolves its
+// glue, preferring an A record already present in the ADDITIONAL section.
+// When no glue is offered, it falls back to a fresh iterative lookup of the
+// NS name's A record.
+func nextNameserver(resp *dns.Msg, domain string) (referral, error) {
+	for _, rr := range resp.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+
+		if glue := findGlue(resp.Extra, ns.Ns); glue != "" {
+			return referral{name: ns.Ns, addr: glue, zone: ns.Hdr.Name}, nil
+		}
+
+		glue, err := resolveGlue(ns.Ns)
+		if err != nil {
+			continue // try the next NS candidate
+		}
+		return referral{name: ns.Ns, addr: glue, zone: ns.Hdr.Name}, nil
+	}
+
+	return referral{}, fmt.Errorf("no usable NS referral for %s", domain)
+}
+
+// findGlue looks for an A record for name among the ADDITIONAL records of a
+// referral response.
+func findGlue(extra []dns.RR, name string) string {
+	for _, rr := range extra {
+		if a, ok := rr.(*dns.A); ok && a.Hdr.Name == name {
+			return a.A.String()
+		}
+	}
+	return ""
+}
+
+// resolveGlue performs a standalone iterative A lookup for a nameserver's
+// name when a referral didn't include glue for it.
+func resolveGlue(name string) (string, error) {
+	hops, err := iterativeResolve(name, dns.TypeA, dns.ClassINET, false, nil)
+	if err != nil {
+		return "", err
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		for _, rr := range hops[i].Answer {
+			if a, ok := rr.(*dns.A); ok {
+				return a.A.String(), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no glue found for %s", name)
+}
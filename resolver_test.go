@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestIsTerminalHop(t *testing.T) {
+	success := dns.RcodeToString[dns.RcodeSuccess]
+	nxdomain := dns.RcodeToString[dns.RcodeNameError]
+
+	ns := &dns.NS{Hdr: dns.RR_Header{Name: "example.", Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 3600}, Ns: "ns1.example."}
+	soa := &dns.SOA{Hdr: dns.RR_Header{Name: "example.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600}}
+
+	cases := []struct {
+		name string
+		hop  Hop
+		want bool
+	}{
+		{"referral has an NS in authority", Hop{Rcode: success, Authority: []dns.RR{ns}}, false},
+		{"NODATA has only SOA in authority", Hop{Rcode: success, Authority: []dns.RR{soa}}, true},
+		{"NODATA with empty authority", Hop{Rcode: success}, true},
+		{"NXDOMAIN is terminal regardless of authority", Hop{Rcode: nxdomain, Authority: []dns.RR{soa}}, true},
+		{"an answer is always terminal", Hop{Rcode: success, Answer: []dns.RR{aRecord("example.", "192.0.2.1")}, Authority: []dns.RR{ns}}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isTerminalHop(tc.hop); got != tc.want {
+				t.Fatalf("isTerminalHop() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestValidateHopContentNodataRequiresAuthenticatedDenial exercises the
+// NODATA path through validateHopContent (a NOERROR rcode with an empty
+// ANSWER), not just NXDOMAIN: it must demand the same signed NSEC/NSEC3
+// proof authenticatedDenial checks, rather than trusting the SOA alone.
+func TestValidateHopContentNodataRequiresAuthenticatedDenial(t *testing.T) {
+	zone := "example."
+	key, signer := generateZoneKey(t, zone)
+	keys := []*dns.DNSKEY{key}
+
+	soa := &dns.SOA{
+		Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+		Ns:  "ns1." + zone, Mbox: "hostmaster." + zone,
+	}
+	nsec := &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: "www." + zone, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 3600},
+		NextDomain: "xxx." + zone,
+		TypeBitMap: []uint16{dns.TypeAAAA, dns.TypeRRSIG, dns.TypeNSEC},
+	}
+	sig := signRRset(t, zone, key, signer, []dns.RR{nsec})
+
+	t.Run("NODATA with a valid NSEC proof is secure", func(t *testing.T) {
+		resp := &dns.Msg{Ns: []dns.RR{soa, nsec, sig}}
+		if status := validateHopContent(resp, keys, true); status != StatusSecure {
+			t.Fatalf("got %s, want SECURE", status)
+		}
+	})
+
+	t.Run("NODATA with an unsigned NSEC is bogus", func(t *testing.T) {
+		resp := &dns.Msg{Ns: []dns.RR{soa, nsec}}
+		if status := validateHopContent(resp, keys, true); status != StatusBogus {
+			t.Fatalf("got %s, want BOGUS", status)
+		}
+	})
+
+	t.Run("NODATA with only an SOA and no NSEC at all is bogus", func(t *testing.T) {
+		resp := &dns.Msg{Ns: []dns.RR{soa}}
+		if status := validateHopContent(resp, keys, true); status != StatusBogus {
+			t.Fatalf("got %s, want BOGUS", status)
+		}
+	})
+}
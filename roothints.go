@@ -0,0 +1,25 @@
+package main
+
+// rootServers lists the 13 IANA root nameservers used to seed an iterative
+// resolution. IPv4 addresses only; a future request can add IPv6 glue.
+var rootServers = []rootServer{
+	{name: "a.root-servers.net", addr: "198.41.0.4"},
+	{name: "b.root-servers.net", addr: "199.9.14.201"},
+	{name: "c.root-servers.net", addr: "192.33.4.12"},
+	{name: "d.root-servers.net", addr: "199.7.91.13"},
+	{name: "e.root-servers.net", addr: "192.203.230.10"},
+	{name: "f.root-servers.net", addr: "192.5.5.241"},
+	{name: "g.root-servers.net", addr: "192.112.36.4"},
+	{name: "h.root-servers.net", addr: "198.97.190.53"},
+	{name: "i.root-servers.net", addr: "192.36.148.17"},
+	{name: "j.root-servers.net", addr: "192.58.128.30"},
+	{name: "k.root-servers.net", addr: "193.0.14.129"},
+	{name: "l.root-servers.net", addr: "199.7.83.42"},
+	{name: "m.root-servers.net", addr: "202.12.27.33"},
+}
+
+// rootServer is a single entry from the bundled root hints.
+type rootServer struct {
+	name string
+	addr string
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	quic "github.com/quic-go/quic-go"
+)
+
+// Default ports for transports that don't carry their own scheme-implied
+// port in a --server value.
+const (
+	defaultDNSPort = "53"
+	dotPort        = "853"
+	doqPort        = "853"
+)
+
+// TransportResult is what any Transport implementation hands back for a
+// single exchange: the response, how long the query/response round trip
+// took, and (for connection-oriented transports) how long the underlying
+// handshake took to establish before the query could even be sent.
+type TransportResult struct {
+	Msg           *dns.Msg
+	RTT           time.Duration
+	HandshakeTime time.Duration
+}
+
+// Transport sends one DNS message to addr and returns the reply. Each
+// implementation is responsible for its own framing (UDP datagram, TCP
+// length-prefix, HTTP body, QUIC stream) and for reporting handshake time
+// honestly (zero where there genuinely isn't one, e.g. plain UDP).
+type Transport interface {
+	// Name is shown in the tree next to each hop, e.g. "UDP", "DoT".
+	Name() string
+	Exchange(m *dns.Msg, addr string) (TransportResult, error)
+}
+
+// NewTransport resolves a --transport flag value and a --server value (which
+// may itself carry a scheme, e.g. "tls://1.1.1.1" or "https://dns.google/dns-query")
+// into a concrete Transport and the address to dial. A scheme on --server
+// always wins over --transport, mirroring how doggo and dog let the server
+// URL imply the protocol. spkiPin, if non-empty, is only honored for tls/dot;
+// it's ignored by every other transport.
+func NewTransport(kind, server, spkiPin string) (transport Transport, addr string, err error) {
+	if scheme, rest, ok := splitScheme(server); ok {
+		kind = scheme
+		server = rest
+	}
+
+	switch strings.ToLower(kind) {
+	case "", "udp":
+		return &classicTransport{}, withPort(server, defaultDNSPort), nil
+	case "tcp":
+		return &classicTransport{forceTCP: true}, withPort(server, defaultDNSPort), nil
+	case "tls", "dot":
+		return &tlsTransport{spkiPin: spkiPin}, withPort(server, dotPort), nil
+	case "https", "doh":
+		return &httpsTransport{endpoint: server}, server, nil
+	case "quic", "doq":
+		return &quicTransport{}, withPort(server, doqPort), nil
+	default:
+		return nil, "", fmt.Errorf("unknown transport: %s", kind)
+	}
+}
+
+// splitScheme peels a "scheme://" prefix off a --server value, if present.
+func splitScheme(server string) (scheme, rest string, ok bool) {
+	i := strings.Index(server, "://")
+	if i < 0 {
+		return "", server, false
+	}
+	return server[:i], server[i+len("://"):], true
+}
+
+// withPort appends a default port to addr if it doesn't already carry one.
+func withPort(addr, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(addr, port)
+}
+
+// classicTransport is plain UDP, falling back to TCP when the server sets
+// the truncation bit. This is the transport the iterative resolver uses for
+// every hop, since arbitrary authoritative servers can't be assumed to speak
+// anything else.
+type classicTransport struct {
+	forceTCP bool
+}
+
+func (t *classicTransport) Name() string {
+	if t.forceTCP {
+		return "TCP"
+	}
+	return "UDP"
+}
+
+func (t *classicTransport) Exchange(m *dns.Msg, addr string) (TransportResult, error) {
+	network := "udp"
+	if t.forceTCP {
+		network = "tcp"
+	}
+	c := &dns.Client{Net: network, Timeout: dnsClientTimeout}
+	resp, rtt, err := c.Exchange(m, addr)
+	if err != nil {
+		return TransportResult{}, err
+	}
+	if !t.forceTCP && resp.Truncated {
+		tcp := &dns.Client{Net: "tcp", Timeout: dnsClientTimeout}
+		resp, rtt, err = tcp.Exchange(m, addr)
+		if err != nil {
+			return TransportResult{}, err
+		}
+	}
+	return TransportResult{Msg: resp, RTT: rtt}, nil
+}
+
+// tlsTransport is DNS-over-TLS (RFC 7858). An optional SPKI pin can be
+// checked against the leaf certificate once the handshake completes.
+type tlsTransport struct {
+	spkiPin string // hex SHA-256 of the leaf SubjectPublicKeyInfo, or "" to skip
+}
+
+func (t *tlsTransport) Name() string { return "DoT" }
+
+func (t *tlsTransport) Exchange(m *dns.Msg, addr string) (TransportResult, error) {
+	dialer := &net.Dialer{Timeout: dnsClientTimeout}
+
+	hsStart := time.Now()
+	raw, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return TransportResult{}, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnsClientTimeout)
+	defer cancel()
+	conn := tls.Client(raw, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return TransportResult{}, err
+	}
+	handshake := time.Since(hsStart)
+
+	if t.spkiPin != "" {
+		if err := verifySPKIPin(conn, t.spkiPin); err != nil {
+			return TransportResult{}, err
+		}
+	}
+
+	co := &dns.Conn{Conn: conn}
+	defer co.Close()
+
+	start := time.Now()
+	if err := co.WriteMsg(m); err != nil {
+		return TransportResult{}, err
+	}
+	resp, err := co.ReadMsg()
+	if err != nil {
+		return TransportResult{}, err
+	}
+
+	return TransportResult{Msg: resp, RTT: time.Since(start), HandshakeTime: handshake}, nil
+}
+
+// verifySPKIPin checks the hex-encoded SHA-256 digest of the server's leaf
+// certificate's SubjectPublicKeyInfo against an expected pin.
+func verifySPKIPin(conn *tls.Conn, pin string) error {
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("no peer certificate to pin against")
+	}
+	sum := sha256.Sum256(certs[0].RawSubjectPublicKeyInfo)
+	got := fmt.Sprintf("%x", sum)
+	if got != pin {
+		return fmt.Errorf("SPKI pin mismatch: got %s, want %s", got, pin)
+	}
+	return nil
+}
+
+// httpsTransport is DNS-over-HTTPS (RFC 8484), POSTing the wire-format
+// message with content-type application/dns-message.
+type httpsTransport struct {
+	endpoint string
+}
+
+func (t *httpsTransport) Name() string { return "DoH" }
+
+func (t *httpsTransport) Exchange(m *dns.Msg, _ string) (TransportResult, error) {
+	packed, err := m.Pack()
+	if err != nil {
+		return TransportResult{}, err
+	}
+
+	var tlsStart time.Time
+	var handshake time.Duration
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { handshake = time.Since(tlsStart) },
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(packed))
+	if err != nil {
+		return TransportResult{}, err
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	client := &http.Client{Timeout: dnsClientTimeout}
+	start := time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		return TransportResult{}, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return TransportResult{}, err
+	}
+	rtt := time.Since(start)
+
+	resp := new(dns.Msg)
+	if err := resp.Unpack(body); err != nil {
+		return TransportResult{}, err
+	}
+
+	return TransportResult{Msg: resp, RTT: rtt, HandshakeTime: handshake}, nil
+}
+
+// quicTransport is DNS-over-QUIC (RFC 9250): one bidirectional stream per
+// query, framed with the same 2-byte length prefix as DNS-over-TCP.
+type quicTransport struct{}
+
+func (t *quicTransport) Name() string { return "DoQ" }
+
+func (t *quicTransport) Exchange(m *dns.Msg, addr string) (TransportResult, error) {
+	host, _, _ := net.SplitHostPort(addr)
+	tlsConf := &tls.Config{ServerName: host, NextProtos: []string{"doq"}, MinVersion: tls.VersionTLS13}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnsClientTimeout)
+	defer cancel()
+
+	hsStart := time.Now()
+	conn, err := quic.DialAddr(ctx, addr, tlsConf, nil)
+	if err != nil {
+		return TransportResult{}, err
+	}
+	handshake := time.Since(hsStart)
+
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return TransportResult{}, err
+	}
+	defer stream.Close()
+
+	packed, err := m.Pack()
+	if err != nil {
+		return TransportResult{}, err
+	}
+
+	start := time.Now()
+	if err := writeLengthPrefixed(stream, packed); err != nil {
+		return TransportResult{}, err
+	}
+	body, err := readLengthPrefixed(stream)
+	if err != nil {
+		return TransportResult{}, err
+	}
+	rtt := time.Since(start)
+
+	resp := new(dns.Msg)
+	if err := resp.Unpack(body); err != nil {
+		return TransportResult{}, err
+	}
+
+	return TransportResult{Msg: resp, RTT: rtt, HandshakeTime: handshake}, nil
+}
+
+// writeLengthPrefixed writes msg as a 2-byte big-endian length followed by
+// the message bytes, the framing DNS-over-TCP and DNS-over-QUIC share.
+func writeLengthPrefixed(w io.Writer, msg []byte) error {
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(msg)))
+	if _, err := w.Write(length[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+// readLengthPrefixed reads one 2-byte big-endian length prefix and the
+// message bytes that follow it.
+func readLengthPrefixed(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestNewTransportWiresSPKIPinForTLSOnly(t *testing.T) {
+	transport, _, err := NewTransport("tls", "1.1.1.1", "deadbeef")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tls, ok := transport.(*tlsTransport)
+	if !ok {
+		t.Fatalf("expected *tlsTransport, got %T", transport)
+	}
+	if tls.spkiPin != "deadbeef" {
+		t.Fatalf("spkiPin = %q, want %q", tls.spkiPin, "deadbeef")
+	}
+
+	udp, _, err := NewTransport("udp", "1.1.1.1", "deadbeef")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := udp.(*classicTransport); !ok {
+		t.Fatalf("expected *classicTransport, got %T", udp)
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "github.com/miekg/dns"
+
+// rootTrustAnchor is the IANA root zone KSK (KSK-2017, tag 20326) published
+// as a DS record. It is the sole starting point for the chain of trust: any
+// DNSKEY that cannot eventually be tied back to this via a run of matching
+// DS records is not secure.
+var rootTrustAnchor = &dns.DS{
+	Hdr:        dns.RR_Header{Name: ".", Rrtype: dns.TypeDS, Class: dns.ClassINET},
+	KeyTag:     20326,
+	Algorithm:  dns.RSASHA256,
+	DigestType: dns.SHA256,
+	Digest:     "E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8",
+}